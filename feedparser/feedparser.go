@@ -19,15 +19,14 @@ package feedparser
 
 import (
 	"bufio"
+	"context"
 	"encoding/gob"
 	"encoding/xml"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
@@ -41,10 +40,16 @@ type PodcastFeed struct {
 }
 
 type Channel struct {
-	XMLName     xml.Name `xml:"channel"`
-	Items       []Item   `xml:"item"`
-	Title       ChannelTitle
-	Description ChannelDescription
+	XMLName        xml.Name `xml:"channel"`
+	Items          []Item   `xml:"item"`
+	Title          ChannelTitle
+	Description    ChannelDescription
+	ItunesAuthor   string           `xml:"author"`
+	ItunesOwner    ItunesOwner      `xml:"owner"`
+	ItunesType     string           `xml:"type"`
+	ItunesImage    ItunesImage      `xml:"image"`
+	ItunesCategory []ItunesCategory `xml:"category"`
+	ItunesExplicit string           `xml:"explicit"`
 }
 
 type ChannelTitle struct {
@@ -57,11 +62,38 @@ type ChannelDescription struct {
 	Description string   `xml:",chardata"`
 }
 
+type ItunesOwner struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+type ItunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type ItunesCategory struct {
+	Text        string           `xml:"text,attr"`
+	SubCategory []ItunesCategory `xml:"category"`
+}
+
 type Item struct {
-	Title      ItemTitle
-	Enclosure  Enclosure
-	Downloaded bool
-	Date       PodcastDate
+	Title             ItemTitle
+	Enclosure         Enclosure
+	Downloaded        bool
+	Date              PodcastDate
+	PubDate           time.Time   `xml:"-"`
+	GUID              GUID        `xml:"guid"`
+	Link              string      `xml:"link"`
+	Description       string      `xml:"description"`
+	ContentEncoded    string      `xml:"encoded"`
+	ItunesDuration    string      `xml:"duration"`
+	ItunesImage       ItunesImage `xml:"image"`
+	ItunesAuthor      string      `xml:"author"`
+	ItunesSummary     string      `xml:"summary"`
+	ItunesEpisode     int         `xml:"episode"`
+	ItunesSeason      int         `xml:"season"`
+	ItunesEpisodeType string      `xml:"episodeType"`
+	ItunesExplicit    string      `xml:"explicit"`
 }
 
 type ItemTitle struct {
@@ -74,6 +106,30 @@ type ItemLink struct {
 	Link    string   `xml:",chardata"`
 }
 
+type GUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// UnmarshalXML defaults IsPermaLink to true when the attribute is
+// omitted, matching the RSS 2.0 spec's default (most feeds rely on this
+// and never write the attribute at all).
+func (g *GUID) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	g.IsPermaLink = true
+	for _, attr := range start.Attr {
+		if attr.Name.Local != "isPermaLink" {
+			continue
+		}
+		isPermaLink, err := strconv.ParseBool(attr.Value)
+		if err != nil {
+			return err
+		}
+		g.IsPermaLink = isPermaLink
+	}
+
+	return d.DecodeElement(&g.Value, &start)
+}
+
 type Enclosure struct {
 	XMLName xml.Name `xml:"enclosure"`
 	URL     string   `xml:"url,attr"`
@@ -86,30 +142,27 @@ type PodcastDate struct {
 	Date    string   `xml:",chardata"`
 }
 
+// pubDateLayout is the RFC 822/2822 layout RSS feeds use for pubDate.
+const pubDateLayout = "Mon, 02 Jan 2006 15:04:05 -0700"
+
 func Parse(podcast configuration.Podcast, wg *sync.WaitGroup, throttle chan int) {
 	defer wg.Done()
-	resp, err := doRequest(podcast.Feed, podcast.Username, podcast.Password)
-	if err != nil {
-		fmt.Printf("Could not fetch feed: %s due to:\t %s\n", podcast.Name, err)
-		return
-	}
-	defer resp.Body.Close()
 
-	var feed PodcastFeed
-	body, err := ioutil.ReadAll(resp.Body)
+	source := NewSource(podcast)
+	items, channelInfo, err := source.Fetch(context.Background(), podcast)
 	if err != nil {
-		fmt.Printf("Unable to read response: %s\n", err)
+		fmt.Printf("Could not fetch feed: %s due to:\t %s\n", podcast.Name, err)
 		return
 	}
 
-	if err := xml.Unmarshal(body, &feed); err != nil {
-		fmt.Printf("Response is not a valid podcast feed: %s\n", err)
-		return
-	}
+	feed := PodcastFeed{Channel: channelInfo}
+	feed.Channel.Items = items
+	parseItemDates(feed)
 
 	cachedFeed := readCachedFeed(podcast)
 	sortFeedByDate(feed)
 	sortFeedByDate(cachedFeed)
+	feed.Channel.Items = filterItems(feed.Channel.Items, podcast)
 	if len(cachedFeed.Channel.Items) < 1 {
 		// NOOP
 	} else if len(feed.Channel.Items) < 1 {
@@ -129,28 +182,107 @@ func Parse(podcast configuration.Podcast, wg *sync.WaitGroup, throttle chan int)
 
 func Download(podcast configuration.Podcast, number int) {
 	feed := readCachedFeed(podcast)
-	url := feed.Channel.Items[number-1].Enclosure.URL
+	item := feed.Channel.Items[number-1]
+	filename := filenameFor(item, number)
 
-	resp, err := doRequest(url, podcast.Username, podcast.Password)
-	if err != nil {
+	bar := pb.New(item.Enclosure.Length).SetUnits(pb.U_BYTES)
+	bar.ShowSpeed = true
+
+	if err := fetchEpisode(context.Background(), podcast, item, filename, bar); err != nil {
 		fmt.Printf("Could not download podcast: %s\n", err)
-		return
 	}
-	defer resp.Body.Close()
+}
+
+// DownloadAll downloads the given episode numbers (1-indexed, matching
+// Download) using a bounded pool of parallel workers. Failed downloads
+// are collected rather than aborting the whole batch, so a flaky episode
+// doesn't stop the rest of the run.
+func DownloadAll(podcast configuration.Podcast, numbers []int, parallel int) []error {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	feed := readCachedFeed(podcast)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pool, err := pb.StartPool()
+	if err != nil {
+		return []error{err}
+	}
+	defer pool.Stop()
+
+	jobs := make(chan int)
+	errs := make(chan error, len(numbers))
+	var wg sync.WaitGroup
+
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range jobs {
+				if err := downloadEpisode(ctx, podcast, feed, number, pool); err != nil {
+					errs <- fmt.Errorf("episode %d: %s", number, err)
+				}
+			}
+		}()
+	}
 
-	tokens := strings.Split(url, "/")
-	filename := tokens[len(tokens)-1]
+	for _, number := range numbers {
+		jobs <- number
+	}
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	var failures []error
+	for err := range errs {
+		failures = append(failures, err)
+	}
+
+	return failures
+}
+
+// DownloadRange downloads every episode number between from and to
+// (inclusive, 1-indexed) using the same worker pool as DownloadAll.
+func DownloadRange(podcast configuration.Podcast, from, to, parallel int) []error {
+	if from > to {
+		return []error{fmt.Errorf("invalid range: from (%d) is greater than to (%d)", from, to)}
+	}
+
+	numbers := make([]int, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		numbers = append(numbers, i)
+	}
+
+	return DownloadAll(podcast, numbers, parallel)
+}
+
+func downloadEpisode(ctx context.Context, podcast configuration.Podcast, feed PodcastFeed, number int, pool *pb.Pool) error {
+	if number < 1 || number > len(feed.Channel.Items) {
+		return fmt.Errorf("no such episode: %d", number)
+	}
+	item := feed.Channel.Items[number-1]
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	filename := filenameFor(item, number)
+
+	bar := pb.New(item.Enclosure.Length).SetUnits(pb.U_BYTES)
+	bar.ShowSpeed = true
+	bar.Prefix(filename)
+	pool.Add(bar)
 
-	writePodcast(podcast, resp.Body, filename, feed.Channel.Items[number-1].Enclosure.Length)
+	return fetchEpisode(ctx, podcast, item, filename, bar)
 }
 
 func GetFileNameForPodcastAndEpisode(podcast configuration.Podcast, number int) string {
 	feed := readCachedFeed(podcast)
-	url := feed.Channel.Items[number-1].Enclosure.URL
-	tokens := strings.Split(url, "/")
-	filename := tokens[len(tokens)-1]
-
-	return filename
+	return filenameFor(feed.Channel.Items[number-1], number)
 }
 
 func ListEpisodes(podcast configuration.Podcast) []Item {
@@ -160,8 +292,7 @@ func ListEpisodes(podcast configuration.Podcast) []Item {
 	for i := 0; i < len(feed.Channel.Items); i++ {
 		item := feed.Channel.Items[i]
 
-		tokens := strings.Split(item.Enclosure.URL, "/")
-		filename := tokens[len(tokens)-1]
+		filename := filenameFor(item, i+1)
 		path := filepath.Join(podcast.Path, filename)
 
 		if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -190,27 +321,11 @@ func doRequest(url, username, password string) (*http.Response, error) {
 	return client.Do(req)
 }
 
-func writePodcast(podcast configuration.Podcast, reader io.Reader, filename string, lengthInBytes int) {
-	path := filepath.Join(podcast.Path, filename)
-	fmt.Printf("Downloading podcast to %s\n", path)
-
-	f, err := os.Create(path)
-	if err != nil {
-		panic("Could not create file")
-	}
-	defer f.Close()
-
-	bar := pb.New(lengthInBytes).SetUnits(pb.U_BYTES)
-	bar.ShowSpeed = true
-	bar.Start()
-
-	mw := io.MultiWriter(f, bar)
-	_, err = io.Copy(mw, reader)
-	if err != nil {
-		panic("Could not download file")
-	}
-	bar.Finish()
-}
+// maxRetries and initialBackoff bound the exponential backoff used by
+// doRequestWithRangeRetry when a download hits a 5xx response or a
+// network error.
+const maxRetries = 3
+const initialBackoff = 500 * time.Millisecond
 
 func writeFeed(podcast configuration.Podcast, feed PodcastFeed) {
 	err := os.MkdirAll(podcast.Path, 0700)
@@ -243,9 +358,12 @@ func readCachedFeed(podcast configuration.Podcast) PodcastFeed {
 }
 
 func sortFeedByDate(feed PodcastFeed) []Item {
-	layout := "Mon, 02 Jan 2006 15:04:05 -0700"
-	firstDate, _ := time.Parse(layout, feed.Channel.Items[0].Date.Date)
-	lastDate, _ := time.Parse(layout, feed.Channel.Items[len(feed.Channel.Items)-1].Date.Date)
+	if len(feed.Channel.Items) < 1 {
+		return feed.Channel.Items
+	}
+
+	firstDate := feed.Channel.Items[0].PubDate
+	lastDate := feed.Channel.Items[len(feed.Channel.Items)-1].PubDate
 
 	if firstDate.Before(lastDate) {
 		// reverse feed
@@ -256,3 +374,17 @@ func sortFeedByDate(feed PodcastFeed) []Item {
 
 	return feed.Channel.Items
 }
+
+// parseItemDates populates PubDate on every item from its raw pubDate
+// chardata, so downstream code (sorting, filenames, listings) never has
+// to reparse the RSS date format itself.
+func parseItemDates(feed PodcastFeed) {
+	for i := range feed.Channel.Items {
+		item := &feed.Channel.Items[i]
+		parsed, err := time.Parse(pubDateLayout, item.Date.Date)
+		if err != nil {
+			continue
+		}
+		item.PubDate = parsed
+	}
+}
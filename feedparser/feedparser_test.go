@@ -0,0 +1,114 @@
+package feedparser
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+// sampleFeedXML mirrors the shape of two real-world podcast feeds this
+// package was written against: full iTunes tags on both the channel and
+// the items, a guid that omits isPermaLink (the common case), and one
+// that sets it explicitly to false.
+const sampleFeedXML = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <channel>
+    <title>Go Time</title>
+    <description>A weekly show about Go.</description>
+    <itunes:author>Changelog Media</itunes:author>
+    <itunes:type>episodic</itunes:type>
+    <itunes:owner>
+      <itunes:name>Changelog Media</itunes:name>
+      <itunes:email>editors@changelog.com</itunes:email>
+    </itunes:owner>
+    <itunes:category text="Technology"/>
+    <item>
+      <title>Episode 300: The one with the itunes tags</title>
+      <link>https://changelog.com/gotime/300</link>
+      <description>Show notes go here.</description>
+      <content:encoded>&lt;p&gt;Show notes go here.&lt;/p&gt;</content:encoded>
+      <guid>gotime-300</guid>
+      <pubDate>Wed, 01 Jan 2025 15:00:00 +0000</pubDate>
+      <itunes:duration>01:12:34</itunes:duration>
+      <itunes:episode>300</itunes:episode>
+      <itunes:season>1</itunes:season>
+      <itunes:episodeType>full</itunes:episodeType>
+      <itunes:explicit>no</itunes:explicit>
+      <itunes:summary>A summary of episode 300.</itunes:summary>
+      <enclosure url="https://cdn.changelog.com/gotime-300.mp3" length="98765" type="audio/mpeg"/>
+    </item>
+    <item>
+      <title>Episode 299: Trailer</title>
+      <guid isPermaLink="false">gotime-299-trailer</guid>
+      <pubDate>Wed, 25 Dec 2024 15:00:00 +0000</pubDate>
+      <itunes:episodeType>trailer</itunes:episodeType>
+      <enclosure url="https://cdn.changelog.com/gotime-299.mp3" length="1234" type="audio/mpeg"/>
+    </item>
+  </channel>
+</rss>`
+
+func TestParseFullMetadata(t *testing.T) {
+	var feed PodcastFeed
+	if err := xml.Unmarshal([]byte(sampleFeedXML), &feed); err != nil {
+		t.Fatalf("Unmarshal returned error: %s", err)
+	}
+
+	if feed.Channel.ItunesAuthor != "Changelog Media" {
+		t.Errorf("ItunesAuthor = %q, want %q", feed.Channel.ItunesAuthor, "Changelog Media")
+	}
+	if feed.Channel.ItunesOwner.Email != "editors@changelog.com" {
+		t.Errorf("ItunesOwner.Email = %q, want %q", feed.Channel.ItunesOwner.Email, "editors@changelog.com")
+	}
+	if len(feed.Channel.ItunesCategory) != 1 || feed.Channel.ItunesCategory[0].Text != "Technology" {
+		t.Errorf("ItunesCategory = %+v, want a single Technology category", feed.Channel.ItunesCategory)
+	}
+
+	if len(feed.Channel.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Channel.Items))
+	}
+
+	first := feed.Channel.Items[0]
+	if first.Link != "https://changelog.com/gotime/300" {
+		t.Errorf("Link = %q, want the episode page URL", first.Link)
+	}
+	if first.ItunesDuration != "01:12:34" {
+		t.Errorf("ItunesDuration = %q, want %q", first.ItunesDuration, "01:12:34")
+	}
+	if first.ItunesEpisode != 300 || first.ItunesSeason != 1 {
+		t.Errorf("ItunesEpisode/ItunesSeason = %d/%d, want 300/1", first.ItunesEpisode, first.ItunesSeason)
+	}
+	if first.ItunesEpisodeType != "full" {
+		t.Errorf("ItunesEpisodeType = %q, want %q", first.ItunesEpisodeType, "full")
+	}
+	if !first.GUID.IsPermaLink {
+		t.Error("GUID.IsPermaLink = false, want true (attribute omitted defaults to true)")
+	}
+
+	second := feed.Channel.Items[1]
+	if second.GUID.IsPermaLink {
+		t.Error("GUID.IsPermaLink = true, want false (attribute explicitly set)")
+	}
+	if second.ItunesEpisodeType != "trailer" {
+		t.Errorf("ItunesEpisodeType = %q, want %q", second.ItunesEpisodeType, "trailer")
+	}
+}
+
+func TestParseItemDatesAndSort(t *testing.T) {
+	// Build a feed whose items arrive oldest-first, the reverse of the
+	// newest-first order sortFeedByDate (and Last) expect.
+	feed := PodcastFeed{}
+	feed.Channel.Items = []Item{
+		{Title: ItemTitle{Title: "older"}, Date: PodcastDate{Date: "Wed, 25 Dec 2024 15:00:00 +0000"}},
+		{Title: ItemTitle{Title: "newer"}, Date: PodcastDate{Date: "Wed, 01 Jan 2025 15:00:00 +0000"}},
+	}
+	parseItemDates(feed)
+
+	if !feed.Channel.Items[0].PubDate.Before(feed.Channel.Items[1].PubDate) {
+		t.Fatalf("expected items[0] to be the older item before sorting")
+	}
+
+	sortFeedByDate(feed)
+
+	if feed.Channel.Items[0].Title.Title != "newer" {
+		t.Errorf("sortFeedByDate did not reorder to newest-first, got %q first", feed.Channel.Items[0].Title.Title)
+	}
+}
@@ -0,0 +1,83 @@
+package feedparser
+
+import (
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// mimeExtensions maps enclosure MIME types to the file extension pcd
+// writes downloads under. ensureExtension appends it, or substitutes it
+// for a URL extension that differs from it; types not listed here leave
+// the URL's own extension untouched.
+var mimeExtensions = map[string]string{
+	"audio/mpeg":  "mp3",
+	"audio/mp4":   "m4a",
+	"audio/x-m4a": "m4a",
+	"audio/ogg":   "ogg",
+	"audio/wav":   "wav",
+	"audio/webm":  "webm",
+	"video/mp4":   "mp4",
+}
+
+// filenameFilter strips characters that are awkward or unsafe in
+// filenames across the platforms pcd runs on, and replaces path
+// separators ("/" and "\") with "-".
+var filenameFilter = strings.NewReplacer(
+	"\"", "",
+	"'", "",
+	"[", "",
+	"]", "",
+	":", "",
+	"/", "-",
+	"\\", "-",
+	"?", "",
+)
+
+// filenameFor derives a deterministic, sortable filename for an episode:
+// <pubDate or sequence>-<base name>.<extension>. index is the item's
+// position in the feed and is only used as a fallback prefix when
+// PubDate couldn't be parsed. It's computed from item alone, with no
+// network round-trip, so GetFileNameForPodcastAndEpisode, ListEpisodes
+// and Publish can all recompute the same name offline from a cached feed.
+func filenameFor(item Item, index int) string {
+	base := filenameFromURL(item.Enclosure.URL)
+	base = ensureExtension(base, item.Enclosure.Type)
+	base = filenameFilter.Replace(base)
+
+	prefix := strconv.Itoa(index)
+	if !item.PubDate.IsZero() {
+		prefix = item.PubDate.Format("2006-01-02")
+	}
+
+	return prefix + "-" + base
+}
+
+func filenameFromURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		tokens := strings.Split(rawURL, "/")
+		return tokens[len(tokens)-1]
+	}
+
+	return path.Base(parsed.Path)
+}
+
+func ensureExtension(filename, mimeType string) string {
+	ext, ok := mimeExtensions[mimeType]
+	if !ok {
+		return filename
+	}
+
+	if strings.HasSuffix(strings.ToLower(filename), "."+ext) {
+		return filename
+	}
+
+	current := path.Ext(filename)
+	if current != "" {
+		filename = strings.TrimSuffix(filename, current)
+	}
+
+	return filename + "." + ext
+}
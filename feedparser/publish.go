@@ -0,0 +1,161 @@
+package feedparser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/kvannotten/pcd/configuration"
+)
+
+// itunesNamespace is the DTD iTunes requires podcast RSS feeds to
+// declare on the root <rss> element.
+const itunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+type publishedFeed struct {
+	XMLName  xml.Name         `xml:"rss"`
+	Version  string           `xml:"version,attr"`
+	ItunesNS string           `xml:"xmlns:itunes,attr"`
+	Channel  publishedChannel `xml:"channel"`
+}
+
+type publishedChannel struct {
+	Title          string            `xml:"title"`
+	Link           string            `xml:"link"`
+	Description    string            `xml:"description"`
+	Language       string            `xml:"language"`
+	ItunesOwner    publishedOwner    `xml:"itunes:owner"`
+	ItunesCategory publishedCategory `xml:"itunes:category"`
+	ItunesExplicit string            `xml:"itunes:explicit"`
+	Items          []publishedItem   `xml:"item"`
+}
+
+type publishedOwner struct {
+	Name  string `xml:"itunes:name"`
+	Email string `xml:"itunes:email"`
+}
+
+type publishedCategory struct {
+	Text string `xml:"text,attr"`
+}
+
+type publishedItem struct {
+	Title          string             `xml:"title"`
+	GUID           publishedGUID      `xml:"guid"`
+	PubDate        string             `xml:"pubDate"`
+	Description    string             `xml:"description"`
+	ItunesDuration string             `xml:"itunes:duration"`
+	ItunesExplicit string             `xml:"itunes:explicit"`
+	Enclosure      publishedEnclosure `xml:"enclosure"`
+}
+
+type publishedGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type publishedEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// channelExplicit reports a feed's itunes:explicit rating. Sources rarely
+// set it at the channel level, so this falls back to the first item that
+// has one rather than defaulting to "no" and mislabeling an explicit
+// podcast as clean.
+func channelExplicit(feed PodcastFeed) string {
+	if feed.Channel.ItunesExplicit != "" {
+		return feed.Channel.ItunesExplicit
+	}
+
+	for _, item := range feed.Channel.Items {
+		if item.ItunesExplicit != "" {
+			return item.ItunesExplicit
+		}
+	}
+
+	return "no"
+}
+
+// Publish walks podcast.Path, cross-references the cached feed for each
+// file that's actually on disk, and writes a spec-compliant iTunes RSS
+// 2.0 document covering only those downloaded episodes. Enclosure URLs
+// are rewritten to baseURL+filename so the emitted feed can be hosted
+// as a private archive of what's actually present locally.
+func Publish(podcast configuration.Podcast, baseURL string, w io.Writer) error {
+	feed := readCachedFeed(podcast)
+
+	entries, err := ioutil.ReadDir(podcast.Path)
+	if err != nil {
+		return fmt.Errorf("could not read podcast directory: %s", err)
+	}
+
+	onDisk := make(map[string]os.FileInfo, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		onDisk[entry.Name()] = entry
+	}
+
+	out := publishedFeed{
+		Version:  "2.0",
+		ItunesNS: itunesNamespace,
+	}
+	out.Channel.Title = feed.Channel.Title.Title
+	out.Channel.Description = feed.Channel.Description.Description
+	out.Channel.Language = "en-us"
+	out.Channel.ItunesOwner = publishedOwner{
+		Name:  feed.Channel.ItunesOwner.Name,
+		Email: feed.Channel.ItunesOwner.Email,
+	}
+	out.Channel.ItunesExplicit = channelExplicit(feed)
+	if len(feed.Channel.ItunesCategory) > 0 {
+		out.Channel.ItunesCategory = publishedCategory{Text: feed.Channel.ItunesCategory[0].Text}
+	}
+
+	for i, item := range feed.Channel.Items {
+		filename := filenameFor(item, i+1)
+		info, ok := onDisk[filename]
+		if !ok {
+			continue
+		}
+
+		out.Channel.Items = append(out.Channel.Items, publishedItem{
+			Title:          item.Title.Title,
+			GUID:           publishedGUID{IsPermaLink: item.GUID.IsPermaLink, Value: item.GUID.Value},
+			PubDate:        item.Date.Date,
+			Description:    item.Description,
+			ItunesDuration: item.ItunesDuration,
+			ItunesExplicit: item.ItunesExplicit,
+			Enclosure: publishedEnclosure{
+				URL:    baseURL + filename,
+				Length: info.Size(),
+				Type:   item.Enclosure.Type,
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(out)
+}
+
+// ServeHandler returns an http.HandlerFunc that serves Publish's output
+// for a single podcast, meant to be mounted per-podcast by `pcd serve`.
+func ServeHandler(podcast configuration.Podcast, baseURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+		if err := Publish(podcast, baseURL, w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package feedparser
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kvannotten/pcd/configuration"
+)
+
+func TestPublishRequiredItunesElements(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pcd-publish-test")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	podcast := configuration.Podcast{Name: "Go Time", Path: dir}
+
+	feed := PodcastFeed{}
+	feed.Channel.Title = ChannelTitle{Title: "Go Time"}
+	feed.Channel.Description = ChannelDescription{Description: "A weekly show about Go."}
+	feed.Channel.ItunesOwner = ItunesOwner{Name: "Changelog Media", Email: "editors@changelog.com"}
+	feed.Channel.ItunesCategory = []ItunesCategory{{Text: "Technology"}}
+
+	item := Item{
+		Title:          ItemTitle{Title: "Episode 300"},
+		GUID:           GUID{Value: "gotime-300", IsPermaLink: false},
+		Date:           PodcastDate{Date: "Wed, 01 Jan 2025 15:00:00 +0000"},
+		Description:    "Show notes go here.",
+		ItunesDuration: "01:12:34",
+		ItunesExplicit: "yes",
+		Enclosure:      Enclosure{URL: "https://cdn.changelog.com/gotime-300.mp3", Type: "audio/mpeg"},
+	}
+	feed.Channel.Items = []Item{item}
+
+	filename := filenameFor(item, 1)
+	if err := os.WriteFile(filepath.Join(dir, filename), []byte("fake mp3 bytes"), 0644); err != nil {
+		t.Fatalf("WriteFile: %s", err)
+	}
+
+	writeFeed(podcast, feed)
+
+	var out strings.Builder
+	if err := Publish(podcast, "https://example.com/", &out); err != nil {
+		t.Fatalf("Publish returned error: %s", err)
+	}
+
+	if !strings.Contains(out.String(), `xmlns:itunes="`+itunesNamespace+`"`) {
+		t.Error("rss element is missing the required xmlns:itunes declaration")
+	}
+
+	var published decodedFeed
+	if err := xml.Unmarshal([]byte(out.String()), &published); err != nil {
+		t.Fatalf("Publish produced unparseable XML: %s\n%s", err, out.String())
+	}
+
+	if published.Channel.Language == "" {
+		t.Error("channel is missing required <language>")
+	}
+	if published.Channel.ItunesOwner.Email == "" {
+		t.Error("channel is missing required itunes:owner/itunes:email")
+	}
+	if published.Channel.ItunesCategory.Text == "" {
+		t.Error("channel is missing required itunes:category")
+	}
+	if published.Channel.ItunesExplicit != "yes" {
+		t.Errorf("channel ItunesExplicit = %q, want %q (sourced from the episode's rating, not a hardcoded default)", published.Channel.ItunesExplicit, "yes")
+	}
+
+	if len(published.Channel.Items) != 1 {
+		t.Fatalf("got %d published items, want 1", len(published.Channel.Items))
+	}
+
+	publishedItem := published.Channel.Items[0]
+	if publishedItem.GUID == "" {
+		t.Error("item is missing required guid")
+	}
+	if publishedItem.Enclosure.URL != "https://example.com/"+filename {
+		t.Errorf("enclosure url = %q, want it rewritten to baseURL+filename", publishedItem.Enclosure.URL)
+	}
+	if publishedItem.Enclosure.Length != int64(len("fake mp3 bytes")) {
+		t.Errorf("enclosure length = %d, want the on-disk file size", publishedItem.Enclosure.Length)
+	}
+	if publishedItem.Enclosure.Type != "audio/mpeg" {
+		t.Errorf("enclosure type = %q, want %q", publishedItem.Enclosure.Type, "audio/mpeg")
+	}
+}
+
+// decodedFeed re-reads Publish's output using plain local element names.
+// publishedFeed's own struct tags (e.g. "itunes:owner") are write-only:
+// encoding/xml matches elements by namespace-resolved local name on
+// decode, so a literal "itunes:" prefix in a tag never matches anything
+// when parsing real XML back in.
+type decodedFeed struct {
+	Channel decodedChannel `xml:"channel"`
+}
+
+type decodedChannel struct {
+	Language       string          `xml:"language"`
+	ItunesOwner    decodedOwner    `xml:"owner"`
+	ItunesCategory decodedCategory `xml:"category"`
+	ItunesExplicit string          `xml:"explicit"`
+	Items          []decodedItem   `xml:"item"`
+}
+
+type decodedOwner struct {
+	Email string `xml:"email"`
+}
+
+type decodedCategory struct {
+	Text string `xml:"text,attr"`
+}
+
+type decodedItem struct {
+	GUID      string           `xml:"guid"`
+	Enclosure decodedEnclosure `xml:"enclosure"`
+}
+
+type decodedEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+func TestPublishSkipsEpisodesNotOnDisk(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pcd-publish-test")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	podcast := configuration.Podcast{Name: "Go Time", Path: dir}
+
+	feed := PodcastFeed{}
+	feed.Channel.Title = ChannelTitle{Title: "Go Time"}
+	feed.Channel.Items = []Item{
+		{
+			Title:     ItemTitle{Title: "Never downloaded"},
+			Date:      PodcastDate{Date: "Wed, 01 Jan 2025 15:00:00 +0000"},
+			Enclosure: Enclosure{URL: "https://cdn.changelog.com/missing.mp3", Type: "audio/mpeg"},
+		},
+	}
+	writeFeed(podcast, feed)
+
+	var out strings.Builder
+	if err := Publish(podcast, "https://example.com/", &out); err != nil {
+		t.Fatalf("Publish returned error: %s", err)
+	}
+
+	var published decodedFeed
+	if err := xml.Unmarshal([]byte(out.String()), &published); err != nil {
+		t.Fatalf("Publish produced unparseable XML: %s\n%s", err, out.String())
+	}
+
+	if len(published.Channel.Items) != 0 {
+		t.Errorf("got %d published items, want 0 for an episode that was never downloaded", len(published.Channel.Items))
+	}
+}
@@ -0,0 +1,335 @@
+package feedparser
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kvannotten/pcd/configuration"
+)
+
+// ChannelInfo carries the feed-level metadata a Source extracts, in the
+// same shape Parse has always cached, so every Source can populate a
+// PodcastFeed the same way regardless of the wire format it read.
+type ChannelInfo = Channel
+
+// Source fetches a podcast's episode list and feed-level metadata from
+// whatever schema the podcast is configured with. RSSSource preserves
+// the historical behavior of Parse; the others let a podcast subscribe
+// to non-RSS publishers uniformly.
+type Source interface {
+	Fetch(ctx context.Context, podcast configuration.Podcast) ([]Item, ChannelInfo, error)
+}
+
+// NewSource picks a Source implementation based on podcast.Schema,
+// defaulting to RSS for podcasts that don't set one so existing
+// configurations keep working unchanged.
+func NewSource(podcast configuration.Podcast) Source {
+	switch podcast.Schema {
+	case "atom":
+		return AtomSource{}
+	case "jsonfeed":
+		return JSONFeedSource{}
+	case "youtube":
+		return YouTubeSource{}
+	default:
+		return RSSSource{}
+	}
+}
+
+// filterItems applies a podcast's TitleContains and Last options. Parse
+// calls this after sortFeedByDate, so Last keeps the N most recent
+// matching episodes whether a Source returned them newest-first or
+// oldest-first. sortFeedByDate only reverses an oldest-first list, so
+// items in any other order are kept as they came.
+func filterItems(items []Item, podcast configuration.Podcast) []Item {
+	if podcast.TitleContains != "" {
+		filtered := items[:0]
+		for _, item := range items {
+			if strings.Contains(item.Title.Title, podcast.TitleContains) {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+
+	if podcast.Last > 0 && podcast.Last < len(items) {
+		items = items[:podcast.Last]
+	}
+
+	return items
+}
+
+// RSSSource is the original behavior of Parse: fetch the feed over HTTP
+// and unmarshal it as RSS 2.0 with the iTunes extensions.
+type RSSSource struct{}
+
+func (RSSSource) Fetch(ctx context.Context, podcast configuration.Podcast) ([]Item, ChannelInfo, error) {
+	resp, err := doRequest(podcast.Feed, podcast.Username, podcast.Password)
+	if err != nil {
+		return nil, ChannelInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ChannelInfo{}, err
+	}
+
+	var feed PodcastFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, ChannelInfo{}, fmt.Errorf("response is not a valid podcast feed: %s", err)
+	}
+
+	return feed.Channel.Items, feed.Channel, nil
+}
+
+// AtomSource reads an Atom 1.0 feed and maps each <entry> onto an Item.
+type AtomSource struct{}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Title   string      `xml:"title"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	Title   string     `xml:"title"`
+	ID      string     `xml:"id"`
+	Updated string     `xml:"updated"`
+	Summary string     `xml:"summary"`
+	Links   []atomLink `xml:"link"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+func (AtomSource) Fetch(ctx context.Context, podcast configuration.Podcast) ([]Item, ChannelInfo, error) {
+	resp, err := doRequest(podcast.Feed, podcast.Username, podcast.Password)
+	if err != nil {
+		return nil, ChannelInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ChannelInfo{}, err
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, ChannelInfo{}, fmt.Errorf("response is not a valid atom feed: %s", err)
+	}
+
+	items := make([]Item, 0, len(feed.Entries))
+	for _, entry := range feed.Entries {
+		var enclosure Enclosure
+		for _, link := range entry.Links {
+			if link.Rel == "enclosure" {
+				enclosure = Enclosure{URL: link.Href, Type: link.Type}
+				break
+			}
+		}
+
+		updated, _ := time.Parse(time.RFC3339, entry.Updated)
+
+		items = append(items, Item{
+			Title:       ItemTitle{Title: entry.Title},
+			GUID:        GUID{Value: entry.ID},
+			Description: entry.Summary,
+			Enclosure:   enclosure,
+			Date:        PodcastDate{Date: updated.Format(pubDateLayout)},
+		})
+	}
+
+	channel := ChannelInfo{Title: ChannelTitle{Title: feed.Title}}
+
+	return items, channel, nil
+}
+
+// JSONFeedSource reads a JSON Feed 1.1 document (jsonfeed.org) and maps
+// each item's first audio/video attachment onto an Enclosure.
+type JSONFeedSource struct{}
+
+type jsonFeedDocument struct {
+	Title string         `json:"title"`
+	Items []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	Title         string               `json:"title"`
+	Summary       string               `json:"summary"`
+	ContentText   string               `json:"content_text"`
+	DatePublished string               `json:"date_published"`
+	Attachments   []jsonFeedAttachment `json:"attachments"`
+}
+
+type jsonFeedAttachment struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	Size     int    `json:"size_in_bytes"`
+}
+
+func (JSONFeedSource) Fetch(ctx context.Context, podcast configuration.Podcast) ([]Item, ChannelInfo, error) {
+	resp, err := doRequest(podcast.Feed, podcast.Username, podcast.Password)
+	if err != nil {
+		return nil, ChannelInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	var doc jsonFeedDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, ChannelInfo{}, fmt.Errorf("response is not a valid JSON feed: %s", err)
+	}
+
+	items := make([]Item, 0, len(doc.Items))
+	for _, jsonItem := range doc.Items {
+		var enclosure Enclosure
+		for _, attachment := range jsonItem.Attachments {
+			if strings.HasPrefix(attachment.MimeType, "audio/") || strings.HasPrefix(attachment.MimeType, "video/") {
+				enclosure = Enclosure{URL: attachment.URL, Type: attachment.MimeType, Length: attachment.Size}
+				break
+			}
+		}
+
+		pubDate, _ := time.Parse(time.RFC3339, jsonItem.DatePublished)
+
+		items = append(items, Item{
+			Title:       ItemTitle{Title: jsonItem.Title},
+			GUID:        GUID{Value: jsonItem.ID},
+			Link:        jsonItem.URL,
+			Description: jsonItem.Summary,
+			Enclosure:   enclosure,
+			PubDate:     pubDate,
+			Date:        PodcastDate{Date: pubDate.Format(pubDateLayout)},
+		})
+	}
+
+	channel := ChannelInfo{Title: ChannelTitle{Title: doc.Title}}
+
+	return items, channel, nil
+}
+
+// YouTubeSource shells out to yt-dlp to enumerate a channel or playlist,
+// then resolves each video individually to a direct, downloadable audio
+// stream URL so the resulting Enclosure can be fetched with a plain
+// HTTP GET like any other podcast episode.
+type YouTubeSource struct{}
+
+// ytDlpFlatEntry is one line of `yt-dlp -j --flat-playlist` output: just
+// enough to enumerate the channel/playlist without resolving formats.
+type ytDlpFlatEntry struct {
+	ID string `json:"id"`
+}
+
+// ytDlpEntry is the JSON `yt-dlp -j -f bestaudio <video>` prints for a
+// single video: full metadata plus the resolved stream URL for the
+// requested format.
+type ytDlpEntry struct {
+	ID          string  `json:"id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	UploadDate  string  `json:"upload_date"`
+	Duration    float64 `json:"duration"`
+	WebpageURL  string  `json:"webpage_url"`
+	URL         string  `json:"url"`
+	Ext         string  `json:"ext"`
+	Filesize    int     `json:"filesize"`
+}
+
+// audioMimeForExt maps the container yt-dlp picked for the best audio
+// format to the MIME type pcd records on the synthesized Enclosure.
+var audioMimeForExt = map[string]string{
+	"m4a":  "audio/mp4",
+	"webm": "audio/webm",
+	"opus": "audio/ogg",
+	"ogg":  "audio/ogg",
+	"mp3":  "audio/mpeg",
+}
+
+func (YouTubeSource) Fetch(ctx context.Context, podcast configuration.Podcast) ([]Item, ChannelInfo, error) {
+	listCmd := exec.CommandContext(ctx, "yt-dlp", "-j", "--flat-playlist", podcast.Feed)
+	out, err := listCmd.Output()
+	if err != nil {
+		return nil, ChannelInfo{}, fmt.Errorf("yt-dlp failed: %s", err)
+	}
+
+	var items []Item
+	decoder := json.NewDecoder(strings.NewReader(string(out)))
+	for decoder.More() {
+		var entry ytDlpFlatEntry
+		if err := decoder.Decode(&entry); err != nil {
+			return nil, ChannelInfo{}, fmt.Errorf("could not decode yt-dlp output: %s", err)
+		}
+
+		item, err := resolveYouTubeAudio(ctx, entry.ID)
+		if err != nil {
+			return nil, ChannelInfo{}, err
+		}
+		items = append(items, item)
+	}
+
+	channel := ChannelInfo{Title: ChannelTitle{Title: podcast.Name}}
+
+	return items, channel, nil
+}
+
+// resolveYouTubeAudio shells out to yt-dlp for a single video to pick
+// its best audio-only format and resolve that format's direct stream
+// URL, since --flat-playlist never resolves formats itself.
+func resolveYouTubeAudio(ctx context.Context, videoID string) (Item, error) {
+	watchURL := "https://www.youtube.com/watch?v=" + videoID
+
+	cmd := exec.CommandContext(ctx, "yt-dlp", "-j", "-f", "bestaudio", watchURL)
+	out, err := cmd.Output()
+	if err != nil {
+		return Item{}, fmt.Errorf("yt-dlp failed to resolve audio for %s: %s", videoID, err)
+	}
+
+	var entry ytDlpEntry
+	if err := json.Unmarshal(out, &entry); err != nil {
+		return Item{}, fmt.Errorf("could not decode yt-dlp output for %s: %s", videoID, err)
+	}
+
+	return itemFromYtDlpEntry(entry), nil
+}
+
+// itemFromYtDlpEntry maps a resolved yt-dlp entry onto an Item. Enclosure.URL
+// must be entry.URL (the resolved audio stream `-f bestaudio` picked), not
+// entry.WebpageURL (the youtube.com/watch page) -- fetchEpisode downloads
+// Enclosure.URL with a plain HTTP GET, so pointing it at the watch page
+// would save HTML instead of audio.
+func itemFromYtDlpEntry(entry ytDlpEntry) Item {
+	pubDate, _ := time.Parse("20060102", entry.UploadDate)
+
+	mimeType, ok := audioMimeForExt[entry.Ext]
+	if !ok {
+		mimeType = "audio/mp4"
+	}
+
+	return Item{
+		Title:          ItemTitle{Title: entry.Title},
+		GUID:           GUID{Value: entry.ID},
+		Link:           entry.WebpageURL,
+		Description:    entry.Description,
+		ItunesDuration: strconv.Itoa(int(entry.Duration)),
+		PubDate:        pubDate,
+		Date:           PodcastDate{Date: pubDate.Format(pubDateLayout)},
+		Enclosure: Enclosure{
+			URL:    entry.URL,
+			Type:   mimeType,
+			Length: entry.Filesize,
+		},
+	}
+}
@@ -0,0 +1,176 @@
+package feedparser
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kvannotten/pcd/configuration"
+)
+
+func TestNewSource(t *testing.T) {
+	cases := []struct {
+		schema string
+		want   Source
+	}{
+		{"rss", RSSSource{}},
+		{"", RSSSource{}},
+		{"atom", AtomSource{}},
+		{"jsonfeed", JSONFeedSource{}},
+		{"youtube", YouTubeSource{}},
+	}
+
+	for _, c := range cases {
+		got := NewSource(configuration.Podcast{Schema: c.schema})
+		if got != c.want {
+			t.Errorf("NewSource(Schema: %q) = %#v, want %#v", c.schema, got, c.want)
+		}
+	}
+}
+
+func TestFilterItems(t *testing.T) {
+	newItems := func() []Item {
+		return []Item{
+			{Title: ItemTitle{Title: "Episode about Go"}},
+			{Title: ItemTitle{Title: "Episode about Rust"}},
+			{Title: ItemTitle{Title: "Another Go episode"}},
+		}
+	}
+
+	filtered := filterItems(newItems(), configuration.Podcast{TitleContains: "Go"})
+	if len(filtered) != 2 {
+		t.Fatalf("got %d items, want 2 matching TitleContains", len(filtered))
+	}
+
+	last := filterItems(newItems(), configuration.Podcast{Last: 2})
+	if len(last) != 2 {
+		t.Fatalf("got %d items, want Last to keep 2", len(last))
+	}
+	if last[0].Title.Title != "Episode about Go" || last[1].Title.Title != "Episode about Rust" {
+		t.Errorf("Last did not keep the first 2 items in the order it was given them: %+v", last)
+	}
+}
+
+func TestRSSSourceFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sampleFeedXML))
+	}))
+	defer server.Close()
+
+	items, channel, err := RSSSource{}.Fetch(context.Background(), configuration.Podcast{Feed: server.URL})
+	if err != nil {
+		t.Fatalf("Fetch returned error: %s", err)
+	}
+
+	if channel.Title.Title != "Go Time" {
+		t.Errorf("channel title = %q, want %q", channel.Title.Title, "Go Time")
+	}
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+}
+
+func TestAtomSourceFetchFormatsDateIntoPubDateLayout(t *testing.T) {
+	const atomXML = `<?xml version="1.0" encoding="UTF-8"?>
+<feed>
+  <title>Go Time</title>
+  <entry>
+    <title>Episode 300</title>
+    <id>gotime-300</id>
+    <updated>2025-01-01T15:00:00Z</updated>
+    <summary>Show notes go here.</summary>
+    <link rel="enclosure" type="audio/mpeg" href="https://cdn.changelog.com/gotime-300.mp3"/>
+  </entry>
+</feed>`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(atomXML))
+	}))
+	defer server.Close()
+
+	items, _, err := AtomSource{}.Fetch(context.Background(), configuration.Podcast{Feed: server.URL})
+	if err != nil {
+		t.Fatalf("Fetch returned error: %s", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+
+	item := items[0]
+	if item.Date.Date != "Wed, 01 Jan 2025 15:00:00 +0000" {
+		t.Errorf("Date.Date = %q, not formatted into pubDateLayout", item.Date.Date)
+	}
+
+	feed := PodcastFeed{Channel: Channel{Items: items}}
+	parseItemDates(feed)
+	if feed.Channel.Items[0].PubDate.IsZero() {
+		t.Error("parseItemDates could not parse the date AtomSource.Fetch produced -- still not in pubDateLayout")
+	}
+}
+
+func TestJSONFeedSourceFetch(t *testing.T) {
+	const jsonFeedDoc = `{
+		"title": "Go Time",
+		"items": [
+			{
+				"id": "gotime-300",
+				"url": "https://changelog.com/gotime/300",
+				"title": "Episode 300",
+				"summary": "Show notes go here.",
+				"date_published": "2025-01-01T15:00:00Z",
+				"attachments": [
+					{"url": "https://cdn.changelog.com/gotime-300.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 98765}
+				]
+			}
+		]
+	}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jsonFeedDoc))
+	}))
+	defer server.Close()
+
+	items, channel, err := JSONFeedSource{}.Fetch(context.Background(), configuration.Podcast{Feed: server.URL})
+	if err != nil {
+		t.Fatalf("Fetch returned error: %s", err)
+	}
+	if channel.Title.Title != "Go Time" {
+		t.Errorf("channel title = %q, want %q", channel.Title.Title, "Go Time")
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1", len(items))
+	}
+
+	item := items[0]
+	if item.Enclosure.URL != "https://cdn.changelog.com/gotime-300.mp3" {
+		t.Errorf("Enclosure.URL = %q, want the audio attachment's URL", item.Enclosure.URL)
+	}
+	if item.Date.Date != "Wed, 01 Jan 2025 15:00:00 +0000" {
+		t.Errorf("Date.Date = %q, want %q", item.Date.Date, "Wed, 01 Jan 2025 15:00:00 +0000")
+	}
+}
+
+func TestItemFromYtDlpEntryUsesResolvedStreamURL(t *testing.T) {
+	entry := ytDlpEntry{
+		ID:         "abc123",
+		Title:      "Episode 300",
+		WebpageURL: "https://www.youtube.com/watch?v=abc123",
+		URL:        "https://rr1.googlevideo.com/videoplayback?id=abc123",
+		Ext:        "m4a",
+		UploadDate: "20250101",
+		Filesize:   98765,
+	}
+
+	item := itemFromYtDlpEntry(entry)
+
+	if item.Enclosure.URL != entry.URL {
+		t.Errorf("Enclosure.URL = %q, want the resolved audio stream URL %q, not the watch page", item.Enclosure.URL, entry.URL)
+	}
+	if item.Enclosure.URL == entry.WebpageURL {
+		t.Error("Enclosure.URL points at the youtube.com watch page; downloads would save HTML instead of audio")
+	}
+	if item.Enclosure.Type != "audio/mp4" {
+		t.Errorf("Enclosure.Type = %q, want %q for ext %q", item.Enclosure.Type, "audio/mp4", entry.Ext)
+	}
+}